@@ -1,9 +1,13 @@
 package twitter
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/dghubble/sling"
 )
@@ -12,6 +16,10 @@ import (
 // endpoints.
 type FriendshipService struct {
 	sling *sling.Sling
+	// RateLimiter, if set, makes Create and Destroy wait for an exhausted
+	// per-endpoint budget to reset before sending, and retry once after a
+	// 429 response. A 429 on that retry is returned to the caller.
+	RateLimiter *RateLimiter
 }
 
 // newFriendshipService returns a new FriendshipService.
@@ -29,16 +37,59 @@ type FriendshipCreateParams struct {
 }
 
 // Create creates a friendship to (i.e. follows) the specified user and
-// returns the followed user.
+// returns the followed user. It is equivalent to CreateContext with
+// context.Background.
 // Requires a user auth context.
 // https://dev.twitter.com/rest/reference/post/friendships/create
 func (s *FriendshipService) Create(params *FriendshipCreateParams) (*User, *http.Response, error) {
+	return s.CreateContext(context.Background(), params)
+}
+
+// CreateContext is Create with a caller-supplied context, used to bound how
+// long it will wait on s.RateLimiter before dispatching the request.
+// Requires a user auth context.
+// https://dev.twitter.com/rest/reference/post/friendships/create
+func (s *FriendshipService) CreateContext(ctx context.Context, params *FriendshipCreateParams) (*User, *http.Response, error) {
+	const path = "friendships/create.json"
+	if err := s.awaitRateLimit(ctx, path); err != nil {
+		return nil, nil, err
+	}
 	user := new(User)
 	apiError := new(APIError)
 	resp, err := s.sling.New().Post("create.json").QueryStruct(params).Receive(user, apiError)
+	s.recordRateLimit(path, resp)
+	if s.RateLimiter != nil {
+		if retried, retryErr := s.RateLimiter.RetryAfter(ctx, resp); retryErr != nil {
+			return nil, resp, retryErr
+		} else if retried {
+			user = new(User)
+			apiError = new(APIError)
+			resp, err = s.sling.New().Post("create.json").QueryStruct(params).Receive(user, apiError)
+			s.recordRateLimit(path, resp)
+		}
+	}
 	return user, resp, relevantError(err, *apiError)
 }
 
+// FriendshipUpdateParams are parameters for FriendshipService.Update
+type FriendshipUpdateParams struct {
+	ScreenName string `url:"screen_name,omitempty"`
+	UserID     int64  `url:"user_id,omitempty"`
+	Device     *bool  `url:"device,omitempty"`
+	Retweets   *bool  `url:"retweets,omitempty"`
+}
+
+// Update enables or disables notifications and retweet visibility for the
+// specified user and returns the updated relationship.
+// Requires a user auth context.
+// https://dev.twitter.com/rest/reference/post/friendships/update
+func (s *FriendshipService) Update(params *FriendshipUpdateParams) (*Relationship, *http.Response, error) {
+	response := new(RelationshipResponse)
+	apiError := new(APIError)
+	resp, err := s.sling.New().Post("update.json").QueryStruct(params).Receive(response, apiError)
+	return response.Relationship, resp, relevantError(err, *apiError)
+}
+
 // FriendshipShowParams are paramenters for FriendshipService.Show
 type FriendshipShowParams struct {
 	SourceID         int64  `url:"source_id,omitempty"`
@@ -109,16 +160,91 @@ type FriendRelationship struct {
 }
 
 // Destroy destroys a friendship to (i.e. unfollows) the specified user and
-// returns the unfollowed user.
+// returns the unfollowed user. It is equivalent to DestroyContext with
+// context.Background.
 // Requires a user auth context.
 // https://dev.twitter.com/rest/reference/post/friendships/destroy
 func (s *FriendshipService) Destroy(params *FriendshipDestroyParams) (*User, *http.Response, error) {
+	return s.DestroyContext(context.Background(), params)
+}
+
+// DestroyContext is Destroy with a caller-supplied context, used to bound
+// how long it will wait on s.RateLimiter before dispatching the request.
+// Requires a user auth context.
+// https://dev.twitter.com/rest/reference/post/friendships/destroy
+func (s *FriendshipService) DestroyContext(ctx context.Context, params *FriendshipDestroyParams) (*User, *http.Response, error) {
+	const path = "friendships/destroy.json"
+	if err := s.awaitRateLimit(ctx, path); err != nil {
+		return nil, nil, err
+	}
 	user := new(User)
 	apiError := new(APIError)
 	resp, err := s.sling.New().Post("destroy.json").QueryStruct(params).Receive(user, apiError)
+	s.recordRateLimit(path, resp)
+	if s.RateLimiter != nil {
+		if retried, retryErr := s.RateLimiter.RetryAfter(ctx, resp); retryErr != nil {
+			return nil, resp, retryErr
+		} else if retried {
+			user = new(User)
+			apiError = new(APIError)
+			resp, err = s.sling.New().Post("destroy.json").QueryStruct(params).Receive(user, apiError)
+			s.recordRateLimit(path, resp)
+		}
+	}
 	return user, resp, relevantError(err, *apiError)
 }
 
+// awaitRateLimit blocks until s.RateLimiter allows another request against
+// path, if a RateLimiter is configured.
+func (s *FriendshipService) awaitRateLimit(ctx context.Context, path string) error {
+	if s.RateLimiter == nil {
+		return nil
+	}
+	return s.RateLimiter.Wait(ctx, path)
+}
+
+// recordRateLimit feeds resp's rate limit headers back into s.RateLimiter,
+// if one is configured.
+func (s *FriendshipService) recordRateLimit(path string, resp *http.Response) {
+	if s.RateLimiter == nil {
+		return
+	}
+	s.RateLimiter.Update(path, resp)
+}
+
+// FriendshipNoRetweetsParams are parameters for FriendshipService.NoRetweets
+type FriendshipNoRetweetsParams struct {
+	StringifyIDs *bool `url:"stringify_ids,omitempty"`
+}
+
+// NoRetweets returns a collection of user IDs for every person whose retweets
+// the authenticating user has turned off. The returned IDs are always
+// decoded to int64, regardless of whether StringifyIDs was set.
+// Requires a user auth context.
+// https://dev.twitter.com/rest/reference/get/friendships/no_retweets/ids
+func (s *FriendshipService) NoRetweets(params *FriendshipNoRetweetsParams) ([]int64, *http.Response, error) {
+	apiError := new(APIError)
+	if params != nil && params.StringifyIDs != nil && *params.StringifyIDs {
+		idStrs := new([]string)
+		resp, err := s.sling.New().Get("no_retweets/ids.json").QueryStruct(params).Receive(idStrs, apiError)
+		if err != nil {
+			return nil, resp, relevantError(err, *apiError)
+		}
+		ids := make([]int64, len(*idStrs))
+		for i, idStr := range *idStrs {
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				return nil, resp, err
+			}
+			ids[i] = id
+		}
+		return ids, resp, relevantError(err, *apiError)
+	}
+	ids := new([]int64)
+	resp, err := s.sling.New().Get("no_retweets/ids.json").QueryStruct(params).Receive(ids, apiError)
+	return *ids, resp, relevantError(err, *apiError)
+}
+
 // FriendshipPendingParams are paramenters for FriendshipService.Outgoing
 type FriendshipPendingParams struct {
 	Cursor int64 `url:"cursor,omitempty"`
@@ -144,6 +270,85 @@ func (s *FriendshipService) Incoming(params *FriendshipPendingParams) (*FriendID
 	return ids, resp, relevantError(err, *apiError)
 }
 
+// FriendIDsPage is one page of results streamed by OutgoingAll or
+// IncomingAll. Err is set on the final page delivered before the stream
+// closes due to a failed request.
+type FriendIDsPage struct {
+	IDs      []int64
+	Response *http.Response
+	Err      error
+}
+
+// OutgoingAll streams every page of FriendshipService.Outgoing over the
+// returned channel, advancing the cursor automatically until
+// next_cursor is 0, ctx is cancelled, or a request fails. The channel is
+// unbuffered and closed after the final page is sent; if the caller stops
+// ranging over it before it is exhausted, ctx MUST be cancelled or the
+// internal goroutine will block forever trying to deliver the next page.
+func (s *FriendshipService) OutgoingAll(ctx context.Context, params *FriendshipPendingParams) <-chan FriendIDsPage {
+	return s.pendingAll(ctx, "outgoing.json", params)
+}
+
+// IncomingAll streams every page of FriendshipService.Incoming over the
+// returned channel, advancing the cursor automatically until
+// next_cursor is 0, ctx is cancelled, or a request fails. The channel is
+// unbuffered and closed after the final page is sent; if the caller stops
+// ranging over it before it is exhausted, ctx MUST be cancelled or the
+// internal goroutine will block forever trying to deliver the next page.
+func (s *FriendshipService) IncomingAll(ctx context.Context, params *FriendshipPendingParams) <-chan FriendIDsPage {
+	return s.pendingAll(ctx, "incoming.json", params)
+}
+
+// pendingAll drives the cursor loop shared by OutgoingAll and IncomingAll.
+// It sends one page per response on an unbuffered channel, so abandoning the
+// returned channel without cancelling ctx leaks this goroutine forever,
+// blocked trying to send the next page.
+func (s *FriendshipService) pendingAll(ctx context.Context, endpoint string, params *FriendshipPendingParams) <-chan FriendIDsPage {
+	pages := make(chan FriendIDsPage)
+	go func() {
+		defer close(pages)
+		reqParams := new(FriendshipPendingParams)
+		if params != nil {
+			*reqParams = *params
+		}
+		reqParams.Cursor = -1
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			ids := new(FriendIDs)
+			apiError := new(APIError)
+			resp, err := s.sling.New().Get(endpoint).QueryStruct(reqParams).Receive(ids, apiError)
+			if err := relevantError(err, *apiError); err != nil {
+				select {
+				case pages <- FriendIDsPage{Response: resp, Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			select {
+			case pages <- FriendIDsPage{IDs: ids.IDs, Response: resp}:
+			case <-ctx.Done():
+				return
+			}
+
+			if ids.NextCursor == 0 {
+				return
+			}
+			reqParams.Cursor = ids.NextCursor
+		}
+	}()
+	return pages
+}
+
+// friendshipLookupBatchSize is the maximum combined number of user IDs and
+// screen names the friendships/lookup endpoint accepts in a single request.
+const friendshipLookupBatchSize = 100
+
 // FriendshipLookupParams are the parameters for FriendshipService.Lookup
 type FriendshipLookupParams struct {
 	UserIDList     []int64
@@ -151,51 +356,143 @@ type FriendshipLookupParams struct {
 	UserID         int64
 	ScreenNameList []string
 	ScreenNameStr  string `url:"screen_name,omitempty"`
+	// MaxConcurrency controls how many batches Lookup issues at once when
+	// the combined input must be split across multiple requests. It
+	// defaults to 1 (sequential) when left unset.
+	MaxConcurrency int `url:"-"`
 }
 
 // Lookup returns a set of friendship status information between the specified user and a list of users.
+// When the combined number of user IDs and screen names exceeds the 100
+// supported by a single request, Lookup transparently splits the input into
+// batches, issues them (optionally with up to MaxConcurrency in flight at
+// once), and concatenates the results. If a batch fails, Lookup returns the
+// relationships gathered so far along with the last HTTP response seen and
+// an error identifying which batch failed.
 // https://developer.twitter.com/en/docs/accounts-and-users/follow-search-get-users/api-reference/get-friendships-lookup
 func (s *FriendshipService) Lookup(params *FriendshipLookupParams) ([]FriendRelationship, *http.Response, error) {
-	relationships := new([]FriendRelationship)
-	transformedParams := new(FriendshipLookupParams)
-	apiError := new(APIError)
+	ids, names := flattenLookupParams(params)
+	batches := chunkLookupParams(ids, names, friendshipLookupBatchSize)
 
-	// Transform params into a comma separated pair of strings
-	transformedParams.ScreenNameStr = strings.Join(params.ScreenNameList, ",")
-	if len(transformedParams.ScreenNameStr) > 0 {
-		transformedParams.ScreenNameStr += ","
+	maxConcurrency := 1
+	if params != nil && params.MaxConcurrency > maxConcurrency {
+		maxConcurrency = params.MaxConcurrency
 	}
-	transformedParams.ScreenNameStr += params.ScreenNameStr
 
-	transformedParams.UserIDStr = params.UserIDStr
-	if params.UserID > 0 {
-		if len(transformedParams.UserIDStr) > 0 {
-			transformedParams.UserIDStr += ","
+	relationships := make([][]FriendRelationship, len(batches))
+	responses := make([]*http.Response, len(batches))
+	errs := make([]error, len(batches))
+
+	var wg sync.WaitGroup
+	var aborted int32
+	sem := make(chan struct{}, maxConcurrency)
+	for i, batch := range batches {
+		sem <- struct{}{}
+		if atomic.LoadInt32(&aborted) != 0 {
+			// An earlier batch already failed; stop spending API calls on
+			// batches that haven't been dispatched yet.
+			<-sem
+			break
 		}
-		transformedParams.UserIDStr += strconv.FormatInt(params.UserID, 10)
+		wg.Add(1)
+		go func(i int, batch *FriendshipLookupParams) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			batchResult := new([]FriendRelationship)
+			apiError := new(APIError)
+			resp, err := s.sling.New().Get("lookup.json").QueryStruct(batch).Receive(batchResult, apiError)
+			relationships[i] = *batchResult
+			responses[i] = resp
+			if err := relevantError(err, *apiError); err != nil {
+				errs[i] = err
+				atomic.StoreInt32(&aborted, 1)
+			}
+		}(i, batch)
 	}
-	for _, id := range params.UserIDList {
-		if len(transformedParams.UserIDStr) > 0 {
-			transformedParams.UserIDStr += ","
+	wg.Wait()
+
+	var all []FriendRelationship
+	var lastResp *http.Response
+	for i, err := range errs {
+		if responses[i] != nil {
+			lastResp = responses[i]
+		}
+		all = append(all, relationships[i]...)
+		if err != nil {
+			return all, lastResp, fmt.Errorf("twitter: friendships/lookup batch %d of %d failed: %w", i+1, len(batches), err)
 		}
-		transformedParams.UserIDStr += strconv.FormatInt(id, 10)
 	}
-	users := strings.Count(transformedParams.UserIDStr, ",")
-	names := strings.Count(transformedParams.ScreenNameStr, ",")
-	if users > 0 {
-		users++
+	return all, lastResp, nil
+}
+
+// flattenLookupParams expands a FriendshipLookupParams into plain slices of
+// user IDs and screen names, combining the List and Str forms of each.
+func flattenLookupParams(params *FriendshipLookupParams) ([]int64, []string) {
+	if params == nil {
+		return nil, nil
+	}
+	ids := append([]int64{}, params.UserIDList...)
+	if params.UserID > 0 {
+		ids = append(ids, params.UserID)
 	}
-	if names > 0 {
-		names++
+	for _, s := range strings.Split(params.UserIDStr, ",") {
+		if s == "" {
+			continue
+		}
+		if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	names := append([]string{}, params.ScreenNameList...)
+	for _, name := range strings.Split(params.ScreenNameStr, ",") {
+		if name != "" {
+			names = append(names, name)
+		}
 	}
-	if names+users > 100 {
-		return nil, nil, APIError{
-			Errors: []ErrorDetail{
-				ErrorDetail{Message: "This API only supports up to 100 users", Code: 200},
-			},
+	return ids, names
+}
+
+// chunkLookupParams splits ids and names into FriendshipLookupParams batches
+// whose combined size never exceeds size, filling each batch with IDs before
+// screen names. It always returns at least one (possibly empty) batch.
+func chunkLookupParams(ids []int64, names []string, size int) []*FriendshipLookupParams {
+	var batches []*FriendshipLookupParams
+	for i, n := 0, 0; i < len(ids) || n < len(names) || len(batches) == 0; {
+		remaining := size
+		batch := new(FriendshipLookupParams)
+
+		if i < len(ids) && remaining > 0 {
+			end := i + remaining
+			if end > len(ids) {
+				end = len(ids)
+			}
+			batch.UserIDStr = joinInt64s(ids[i:end])
+			remaining -= end - i
+			i = end
+		}
+		if n < len(names) && remaining > 0 {
+			end := n + remaining
+			if end > len(names) {
+				end = len(names)
+			}
+			batch.ScreenNameStr = strings.Join(names[n:end], ",")
+			n = end
+		}
+
+		batches = append(batches, batch)
+		if i >= len(ids) && n >= len(names) {
+			break
 		}
 	}
+	return batches
+}
 
-	resp, err := s.sling.New().Get("lookup.json").QueryStruct(transformedParams).Receive(relationships, apiError)
-	return *relationships, resp, relevantError(err, *apiError)
+// joinInt64s formats ids as a comma separated string.
+func joinInt64s(ids []int64) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(strs, ",")
 }
@@ -0,0 +1,158 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlattenLookupParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    *FriendshipLookupParams
+		wantIDs   []int64
+		wantNames []string
+	}{
+		{
+			name:   "nil params",
+			params: nil,
+		},
+		{
+			name:      "empty params",
+			params:    &FriendshipLookupParams{},
+			wantIDs:   []int64{},
+			wantNames: []string{},
+		},
+		{
+			name: "combines list and str forms",
+			params: &FriendshipLookupParams{
+				UserIDList:     []int64{1, 2},
+				UserIDStr:      "3,4",
+				UserID:         5,
+				ScreenNameList: []string{"a", "b"},
+				ScreenNameStr:  "c,d",
+			},
+			wantIDs:   []int64{1, 2, 5, 3, 4},
+			wantNames: []string{"a", "b", "c", "d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, names := flattenLookupParams(tt.params)
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("flattenLookupParams() ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("flattenLookupParams() names = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestChunkLookupParams(t *testing.T) {
+	ids := make([]int64, 150)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	names := []string{"a", "b", "c"}
+
+	batches := chunkLookupParams(ids, names, 100)
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(batches))
+	}
+
+	firstIDs := strings.Split(batches[0].UserIDStr, ",")
+	if len(firstIDs) != 100 {
+		t.Errorf("expected the first batch to hold 100 ids, got %d", len(firstIDs))
+	}
+	if batches[0].ScreenNameStr != "" {
+		t.Errorf("expected the first batch to hold no screen names, got %q", batches[0].ScreenNameStr)
+	}
+
+	secondIDs := strings.Split(batches[1].UserIDStr, ",")
+	if len(secondIDs) != 50 {
+		t.Errorf("expected the second batch to hold the remaining 50 ids, got %d", len(secondIDs))
+	}
+	if batches[1].ScreenNameStr != strings.Join(names, ",") {
+		t.Errorf("expected the second batch to hold all screen names, got %q", batches[1].ScreenNameStr)
+	}
+}
+
+func TestChunkLookupParamsEmptyInputReturnsOneBatch(t *testing.T) {
+	batches := chunkLookupParams(nil, nil, 100)
+	if len(batches) != 1 {
+		t.Fatalf("expected exactly one (empty) batch, got %d", len(batches))
+	}
+	if batches[0].UserIDStr != "" || batches[0].ScreenNameStr != "" {
+		t.Errorf("expected an empty batch, got %+v", batches[0])
+	}
+}
+
+func TestLookupStopsDispatchingAfterFailedBatch(t *testing.T) {
+	var requests int32
+	s, server := newTestFriendshipService(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errors":[{"message":"Bad token","code":89}]}`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+	defer server.Close()
+
+	ids := make([]int64, 250) // splits into 3 batches of 100/100/50
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+
+	_, _, err := s.Lookup(&FriendshipLookupParams{UserIDList: ids})
+	if err == nil {
+		t.Fatal("expected an error from the failing first batch")
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("expected Lookup to stop dispatching after the first failing batch, but it issued %d requests", got)
+	}
+}
+
+// TestFriendshipServiceIncomingAllStopsOnContextCancellation exercises the
+// cancellation contract documented on IncomingAll/OutgoingAll/pendingAll: a
+// caller that stops ranging over the page channel must cancel ctx, or the
+// producer goroutine blocks forever trying to send the next page.
+func TestFriendshipServiceIncomingAllStopsOnContextCancellation(t *testing.T) {
+	s, server := newTestFriendshipService(func(w http.ResponseWriter, r *http.Request) {
+		// next_cursor is always non-zero, so without cancellation this
+		// stream never ends on its own.
+		fmt.Fprint(w, `{"ids": [1, 2, 3], "next_cursor": 1, "previous_cursor": 0}`)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := s.IncomingAll(ctx, nil)
+
+	page := <-pages
+	if page.Err != nil {
+		t.Fatalf("unexpected error on first page: %v", page.Err)
+	}
+
+	cancel()
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-pages:
+			if !ok {
+				return
+			}
+			// A page already in flight when ctx was cancelled may still be
+			// delivered; keep draining until the channel closes.
+		case <-timeout:
+			t.Fatal("expected the page channel to be closed shortly after ctx cancellation")
+		}
+	}
+}
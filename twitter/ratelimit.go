@@ -0,0 +1,114 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter tracks per-endpoint request budgets using the
+// X-Rate-Limit-Remaining and X-Rate-Limit-Reset headers Twitter returns on
+// every response, blocking callers until a budget resets instead of letting
+// them walk into a 429. Budgets are tracked per request path, so a single
+// RateLimiter may be shared by several callers in place of the ad-hoc
+// time.Sleep(1 * time.Minute) pattern; in this package only
+// FriendshipService has a RateLimiter field. It is safe for concurrent use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]rateLimitBucket
+}
+
+type rateLimitBucket struct {
+	remaining int64
+	reset     time.Time
+}
+
+// NewRateLimiter returns a RateLimiter with no tracked budgets.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[string]rateLimitBucket)}
+}
+
+// Wait blocks until path's tracked budget allows another request, or until
+// ctx is done. Paths with no tracked budget yet are allowed through.
+func (r *RateLimiter) Wait(ctx context.Context, path string) error {
+	r.mu.Lock()
+	bucket, tracked := r.buckets[path]
+	r.mu.Unlock()
+	if !tracked || bucket.remaining > 0 {
+		return nil
+	}
+	return sleepUntil(ctx, bucket.reset)
+}
+
+// Update records the remaining budget and reset time resp reports for path.
+// It is a no-op if resp is nil or carries no rate limit headers.
+func (r *RateLimiter) Update(path string, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	remaining, ok := parseRateLimitHeader(resp.Header.Get("X-Rate-Limit-Remaining"))
+	if !ok {
+		return
+	}
+	resetUnix, ok := parseRateLimitHeader(resp.Header.Get("X-Rate-Limit-Reset"))
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buckets == nil {
+		r.buckets = make(map[string]rateLimitBucket)
+	}
+	r.buckets[path] = rateLimitBucket{
+		remaining: remaining,
+		reset:     time.Unix(resetUnix, 0),
+	}
+}
+
+// RetryAfter reports whether resp is a 429 response that should be retried,
+// and if so blocks for the duration named by its Retry-After header
+// (defaulting to 60 seconds if the header is absent) or until ctx is done,
+// whichever comes first. If ctx is cancelled while waiting, it returns
+// false along with ctx's error so the caller knows not to retry.
+func (r *RateLimiter) RetryAfter(ctx context.Context, resp *http.Response) (bool, error) {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return false, nil
+	}
+	seconds, ok := parseRateLimitHeader(resp.Header.Get("Retry-After"))
+	if !ok {
+		seconds = 60
+	}
+	if err := sleepUntil(ctx, time.Now().Add(time.Duration(seconds)*time.Second)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func sleepUntil(ctx context.Context, when time.Time) error {
+	d := time.Until(when)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func parseRateLimitHeader(value string) (int64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,142 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newRateLimitResponse(remaining int64, reset time.Time) *http.Response {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Rate-Limit-Remaining", strconv.FormatInt(remaining, 10))
+	rec.Header().Set("X-Rate-Limit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	rec.WriteHeader(http.StatusOK)
+	return rec.Result()
+}
+
+func newTooManyRequestsResponse(retryAfter string) *http.Response {
+	rec := httptest.NewRecorder()
+	if retryAfter != "" {
+		rec.Header().Set("Retry-After", retryAfter)
+	}
+	rec.WriteHeader(http.StatusTooManyRequests)
+	return rec.Result()
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining int64
+		resetIn   time.Duration
+		maxWait   time.Duration
+	}{
+		{
+			name:      "budget available does not block",
+			remaining: 5,
+			resetIn:   time.Hour,
+			maxWait:   50 * time.Millisecond,
+		},
+		{
+			// X-Rate-Limit-Reset carries whole Unix seconds, so the reset
+			// lands between resetIn-1s and resetIn from now.
+			name:      "exhausted budget blocks until reset",
+			remaining: 0,
+			resetIn:   3 * time.Second,
+			maxWait:   4 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRateLimiter()
+			r.Update("friendships/create.json", newRateLimitResponse(tt.remaining, time.Now().Add(tt.resetIn)))
+
+			start := time.Now()
+			err := r.Wait(context.Background(), "friendships/create.json")
+			elapsed := time.Since(start)
+
+			if err != nil {
+				t.Fatalf("Wait returned unexpected error: %v", err)
+			}
+			if elapsed > tt.maxWait {
+				t.Errorf("Wait took %v, expected at most %v", elapsed, tt.maxWait)
+			}
+			if tt.remaining == 0 && elapsed < tt.resetIn/2 {
+				t.Errorf("Wait returned after %v, expected to block roughly until the %v reset", elapsed, tt.resetIn)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWaitContextCancellation(t *testing.T) {
+	r := NewRateLimiter()
+	r.Update("friendships/create.json", newRateLimitResponse(0, time.Now().Add(time.Hour)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := r.Wait(ctx, "friendships/create.json")
+	elapsed := time.Since(start)
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Wait blocked for %v instead of returning once ctx expired", elapsed)
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	tests := []struct {
+		name        string
+		resp        *http.Response
+		wantRetried bool
+	}{
+		{
+			name:        "429 with Retry-After is retried",
+			resp:        newTooManyRequestsResponse("0"),
+			wantRetried: true,
+		},
+		{
+			name:        "200 response is not retried",
+			resp:        newRateLimitResponse(5, time.Now().Add(time.Hour)),
+			wantRetried: false,
+		},
+		{
+			name:        "nil response is not retried",
+			resp:        nil,
+			wantRetried: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRateLimiter()
+			retried, err := r.RetryAfter(context.Background(), tt.resp)
+			if err != nil {
+				t.Fatalf("RetryAfter returned unexpected error: %v", err)
+			}
+			if retried != tt.wantRetried {
+				t.Errorf("RetryAfter() = %v, want %v", retried, tt.wantRetried)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRetryAfterContextCancellation(t *testing.T) {
+	r := NewRateLimiter()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	retried, err := r.RetryAfter(ctx, newTooManyRequestsResponse("60"))
+	if err == nil {
+		t.Fatal("expected RetryAfter to return an error when ctx is already cancelled")
+	}
+	if retried {
+		t.Error("expected RetryAfter to report no retry when ctx is cancelled")
+	}
+}
@@ -0,0 +1,17 @@
+package twitter
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/dghubble/sling"
+)
+
+// newTestFriendshipService starts an httptest.Server driven by handler and
+// returns a FriendshipService pointed at it. The caller must close the
+// returned server.
+func newTestFriendshipService(handler http.HandlerFunc) (*FriendshipService, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	s := newFriendshipService(sling.New().Client(server.Client()).Base(server.URL + "/"))
+	return s, server
+}